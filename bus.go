@@ -0,0 +1,401 @@
+package i2c
+
+import (
+	"sync"
+	"unsafe"
+)
+
+// SMBusRW is the read/write bit of the kernel's i2c_smbus_ioctl_data.
+// It is exported so the smbus subpackage and testing/mocki2c share this
+// package's definition instead of each mirroring the kernel ABI on their
+// own.
+type SMBusRW byte
+
+const (
+	SMBusWrite SMBusRW = iota
+	SMBusRead
+)
+
+// SMBusSize values are the I2C_SMBUS_* transaction sizes used by the
+// SMBUS ioctl, in the kernel's own order.
+type SMBusSize uint32
+
+const (
+	SMBusQuick SMBusSize = iota
+	SMBusByte
+	SMBusByteData
+	SMBusWordData
+	SMBusProcCall
+	SMBusBlockData
+	SMBusI2CBlockBroken
+	SMBusBlockProcCall
+	SMBusI2CBlockData
+)
+
+// SMBusMsg mirrors the kernel's struct i2c_smbus_ioctl_data: the single
+// definition of this layout, shared by this package's Device, the smbus
+// subpackage, and testing/mocki2c.
+type SMBusMsg struct {
+	RW      SMBusRW
+	Command byte
+	pad     [2]byte
+	Size    SMBusSize
+	Data    uintptr
+}
+
+// Bus serializes access to a shared *File across goroutines that each
+// talk to a different slave address. A raw i2c.File has exactly one
+// current slave address (set via SLAVE/SLAVE_FORCE), so two goroutines
+// using it for different addresses without coordination would race on
+// that ioctl; Bus re-issues it only when the cached address changes.
+type Bus struct {
+	mu      sync.Mutex
+	f       *File
+	addr    uint16
+	tenbit  bool
+	addrSet bool
+}
+
+// NewBus wraps f for concurrency-safe, per-address access through Device.
+func NewBus(f *File) *Bus {
+	return &Bus{f: f}
+}
+
+// Device returns a handle bound to addr on b. Every Device method locks b
+// for its duration and re-issues SLAVE only if the previous caller left a
+// different address selected; Device never uses SLAVE_FORCE.
+func (b *Bus) Device(addr uint16, tenbit bool) *Device {
+	d := &Device{bus: b, addr: addr, tenbit: tenbit}
+	if funcs, err := b.f.Funcs(); err == nil {
+		d.funcs = funcs
+		d.funcsOK = true
+	}
+	return d
+}
+
+// selectLocked issues SLAVE/SLAVE_FORCE for addr/tenbit if they differ
+// from what is currently selected on the bus. b.mu must be held.
+func (b *Bus) selectLocked(addr uint16, tenbit bool, force bool) error {
+	if b.addrSet && b.addr == addr && b.tenbit == tenbit {
+		return nil
+	}
+	if tenbit != b.tenbit {
+		if err := b.f.SetTenbit(tenbit); err != nil {
+			return err
+		}
+		b.tenbit = tenbit
+	}
+	if err := b.f.SetSlaveAddr(addr, force); err != nil {
+		return err
+	}
+	b.addr = addr
+	b.addrSet = true
+	return nil
+}
+
+// Device is a slave address on a Bus. It is safe for concurrent use: each
+// method acquires the Bus lock for the duration of the transaction.
+type Device struct {
+	bus    *Bus
+	addr   uint16
+	tenbit bool
+
+	funcs   Funcs
+	funcsOK bool
+}
+
+// RequiredSMBusFunc returns the FuncSMBus* bit (and a human-readable
+// name) the adapter must report to carry out an SMBus transaction of
+// size sz and direction rw, mirroring the kernel's i2c-core-smbus check.
+// It is exported so this package's Device and the smbus subpackage
+// share one definition of the mapping instead of each mirroring the
+// kernel's switch independently.
+func RequiredSMBusFunc(sz SMBusSize, rw SMBusRW) (Funcs, string) {
+	switch sz {
+	case SMBusQuick:
+		return FuncSMBusQuick, "SMBus Quick"
+	case SMBusByte:
+		if rw == SMBusRead {
+			return FuncSMBusReadByte, "SMBus Read Byte"
+		}
+		return FuncSMBusWriteByte, "SMBus Write Byte"
+	case SMBusByteData:
+		if rw == SMBusRead {
+			return FuncSMBusReadByteData, "SMBus Read Byte Data"
+		}
+		return FuncSMBusWriteByteData, "SMBus Write Byte Data"
+	case SMBusWordData:
+		if rw == SMBusRead {
+			return FuncSMBusReadWordData, "SMBus Read Word Data"
+		}
+		return FuncSMBusWriteWordData, "SMBus Write Word Data"
+	case SMBusProcCall:
+		return FuncSMBusProcCall, "SMBus Process Call"
+	case SMBusBlockData:
+		if rw == SMBusRead {
+			return FuncSMBusReadBlockData, "SMBus Read Block Data"
+		}
+		return FuncSMBusWriteBlockData, "SMBus Write Block Data"
+	case SMBusI2CBlockBroken, SMBusI2CBlockData:
+		if rw == SMBusRead {
+			return FuncSMBusReadI2CBlock, "SMBus Read I2C Block Data"
+		}
+		return FuncSMBusWriteI2CBlock, "SMBus Write I2C Block Data"
+	case SMBusBlockProcCall:
+		return FuncSMBusBlockProcCall, "SMBus Block Process Call"
+	}
+	return 0, ""
+}
+
+// Read reads up to len(p) bytes directly from the device, with no
+// register addressing.
+func (d *Device) Read(p []byte) (int, error) {
+	d.bus.mu.Lock()
+	defer d.bus.mu.Unlock()
+	if err := d.bus.selectLocked(d.addr, d.tenbit, false); err != nil {
+		return 0, err
+	}
+	return d.bus.f.Read(p)
+}
+
+// Write writes p directly to the device, with no register addressing.
+func (d *Device) Write(p []byte) (int, error) {
+	d.bus.mu.Lock()
+	defer d.bus.mu.Unlock()
+	if err := d.bus.selectLocked(d.addr, d.tenbit, false); err != nil {
+		return 0, err
+	}
+	return d.bus.f.Write(p)
+}
+
+// WriteRead performs a combined write-then-read as a single I2C_RDWR
+// transaction: w is written, then a repeated START reads into r.
+func (d *Device) WriteRead(w, r []byte) error {
+	d.bus.mu.Lock()
+	defer d.bus.mu.Unlock()
+	flags := uint16(0)
+	if d.tenbit {
+		flags |= I2C_M_TEN
+	}
+	msgs := []Msg{
+		{Addr: d.addr, Flags: flags, Buf: w},
+		{Addr: d.addr, Flags: flags | I2C_M_RD, Buf: r},
+	}
+	return d.bus.f.Transfer(msgs)
+}
+
+// ErrUnsupported is returned by a Device SMBus method when the adapter
+// lacks the capability the transaction requires, as reported by
+// I2C_FUNCS, mirroring smbus.ErrUnsupported for smbus.File.
+type ErrUnsupported struct {
+	Func string
+}
+
+func (e *ErrUnsupported) Error() string {
+	return "i2c: adapter does not support " + e.Func
+}
+
+func (d *Device) smbusDo(msg *SMBusMsg) error {
+	if d.funcsOK {
+		if need, name := RequiredSMBusFunc(msg.Size, msg.RW); need != 0 && !d.funcs.Has(need) {
+			return &ErrUnsupported{Func: name}
+		}
+	}
+	d.bus.mu.Lock()
+	defer d.bus.mu.Unlock()
+	if err := d.bus.selectLocked(d.addr, d.tenbit, false); err != nil {
+		return err
+	}
+	return d.bus.f.IoctlPtr(SMBUS, unsafe.Pointer(msg))
+}
+
+// WriteQuick sends a single bit to the device, at the place of the
+// Rd/Wr bit. There is no equivalent Read Quick command.
+func (d *Device) WriteQuick(rw SMBusRW) error {
+	msg := SMBusMsg{RW: rw, Size: SMBusQuick}
+	return d.smbusDo(&msg)
+}
+
+// ReadByte reads a single byte from the device, without specifying a
+// register.
+func (d *Device) ReadByte() (byte, error) {
+	var data [4]byte
+	msg := SMBusMsg{
+		RW:   SMBusRead,
+		Size: SMBusByte,
+		Data: uintptr(unsafe.Pointer(&data[0])),
+	}
+	if err := d.smbusDo(&msg); err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// WriteByte sends a single byte to the device, without specifying a
+// register.
+func (d *Device) WriteByte(data byte) error {
+	msg := SMBusMsg{RW: SMBusWrite, Command: data, Size: SMBusByte}
+	return d.smbusDo(&msg)
+}
+
+// ReadByteData reads a single byte from reg.
+func (d *Device) ReadByteData(reg byte) (byte, error) {
+	var data [4]byte
+	msg := SMBusMsg{
+		RW:      SMBusRead,
+		Command: reg,
+		Size:    SMBusByteData,
+		Data:    uintptr(unsafe.Pointer(&data[0])),
+	}
+	if err := d.smbusDo(&msg); err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// WriteByteData writes a single byte to reg.
+func (d *Device) WriteByteData(reg, data byte) error {
+	msg := SMBusMsg{
+		RW:      SMBusWrite,
+		Command: reg,
+		Size:    SMBusByteData,
+		Data:    uintptr(unsafe.Pointer(&data)),
+	}
+	return d.smbusDo(&msg)
+}
+
+// ReadWordData reads a 16 bit word from reg.
+func (d *Device) ReadWordData(reg byte) (uint16, error) {
+	var data uint16
+	msg := SMBusMsg{
+		RW:      SMBusRead,
+		Command: reg,
+		Size:    SMBusWordData,
+		Data:    uintptr(unsafe.Pointer(&data)),
+	}
+	if err := d.smbusDo(&msg); err != nil {
+		return 0, err
+	}
+	return data, nil
+}
+
+// WriteWordData writes a 16 bit word to reg.
+func (d *Device) WriteWordData(reg byte, data uint16) error {
+	msg := SMBusMsg{
+		RW:      SMBusWrite,
+		Command: reg,
+		Size:    SMBusWordData,
+		Data:    uintptr(unsafe.Pointer(&data)),
+	}
+	return d.smbusDo(&msg)
+}
+
+// ProcessCall writes a 16 bit word to reg, then reads back a 16 bit word
+// in the same transaction.
+func (d *Device) ProcessCall(reg byte, data uint16) (uint16, error) {
+	val := data
+	msg := SMBusMsg{
+		RW:      SMBusWrite,
+		Command: reg,
+		Size:    SMBusProcCall,
+		Data:    uintptr(unsafe.Pointer(&val)),
+	}
+	if err := d.smbusDo(&msg); err != nil {
+		return 0, err
+	}
+	return val, nil
+}
+
+// ReadBlockData reads a block of up to 32 bytes from reg; the device
+// reports the block length as its first returned byte.
+func (d *Device) ReadBlockData(reg byte) ([]byte, error) {
+	var data [34]byte
+	msg := SMBusMsg{
+		RW:      SMBusRead,
+		Command: reg,
+		Size:    SMBusBlockData,
+		Data:    uintptr(unsafe.Pointer(&data[0])),
+	}
+	if err := d.smbusDo(&msg); err != nil {
+		return nil, err
+	}
+	n := data[0]
+	return data[1 : n+1], nil
+}
+
+// WriteBlockData writes up to 32 bytes of data to reg.
+func (d *Device) WriteBlockData(reg byte, data []byte) (int, error) {
+	var buf [34]byte
+	n := copy(buf[1:], data)
+	buf[0] = byte(n)
+	msg := SMBusMsg{
+		RW:      SMBusWrite,
+		Command: reg,
+		Size:    SMBusBlockData,
+		Data:    uintptr(unsafe.Pointer(&buf[0])),
+	}
+	return n, d.smbusDo(&msg)
+}
+
+// BlockProcessCall writes a block of up to 31 bytes to reg, then reads
+// back a block of up to 31 bytes in the same transaction.
+func (d *Device) BlockProcessCall(reg byte, data []byte) ([]byte, error) {
+	var buf [34]byte
+	n := copy(buf[1:], data)
+	buf[0] = byte(n)
+	msg := SMBusMsg{
+		RW:      SMBusWrite,
+		Command: reg,
+		Size:    SMBusBlockProcCall,
+		Data:    uintptr(unsafe.Pointer(&buf[0])),
+	}
+	if err := d.smbusDo(&msg); err != nil {
+		return nil, err
+	}
+	return buf[1 : buf[0]+1], nil
+}
+
+// ReadI2CBlockData reads a block of up to 32 bytes from reg, using the
+// I2C block transfer rather than the SMBus block transfer (no length
+// byte is exchanged with the device; up to length bytes are read).
+// length is clamped to [0, 32], the limit the SMBus layer imposes on an
+// I2C block transaction.
+func (d *Device) ReadI2CBlockData(reg byte, length int) ([]byte, error) {
+	if length < 0 {
+		length = 0
+	} else if length > 32 {
+		length = 32
+	}
+	var buf [34]byte
+	buf[0] = byte(length)
+	sz := SMBusI2CBlockData
+	if length == 32 {
+		sz = SMBusI2CBlockBroken
+	}
+	msg := SMBusMsg{
+		RW:      SMBusRead,
+		Command: reg,
+		Size:    sz,
+		Data:    uintptr(unsafe.Pointer(&buf[0])),
+	}
+	if err := d.smbusDo(&msg); err != nil {
+		return nil, err
+	}
+	n := buf[0]
+	return buf[1 : n+1], nil
+}
+
+// WriteI2CBlockData writes data to reg, using the I2C block transfer
+// rather than the SMBus block transfer.
+func (d *Device) WriteI2CBlockData(reg byte, data []byte) (int, error) {
+	var buf [34]byte
+	n := copy(buf[1:], data)
+	buf[0] = byte(n)
+	msg := SMBusMsg{
+		RW:      SMBusWrite,
+		Command: reg,
+		Size:    SMBusI2CBlockBroken,
+		Data:    uintptr(unsafe.Pointer(&buf[0])),
+	}
+	return n, d.smbusDo(&msg)
+}
@@ -0,0 +1,77 @@
+package i2c
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const i2cDevClassPath = "/sys/class/i2c-dev"
+
+// BusInfo describes one I2C adapter registered on the system, as found
+// under /sys/class/i2c-dev.
+type BusInfo struct {
+	Bus     int
+	Name    string // e.g. "i2c-1"
+	Path    string // e.g. "/dev/i2c-1"
+	Adapter string // the adapter driver's human-readable name
+}
+
+// Enumerate lists every I2C adapter present on the system by scanning
+// /sys/class/i2c-dev. Bus numbers are not guaranteed to be stable across
+// kernels or boards, so callers that need a specific adapter should match
+// on BusInfo.Adapter (see OpenByName) rather than hard-coding Bus.
+func Enumerate() ([]BusInfo, error) {
+	entries, err := os.ReadDir(i2cDevClassPath)
+	if err != nil {
+		return nil, err
+	}
+	var buses []BusInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		bus, ok := parseBusNum(name)
+		if !ok {
+			continue
+		}
+		adapter, err := os.ReadFile(filepath.Join(i2cDevClassPath, name, "name"))
+		if err != nil {
+			return nil, err
+		}
+		buses = append(buses, BusInfo{
+			Bus:     bus,
+			Name:    name,
+			Path:    fmt.Sprintf("/dev/%s", name),
+			Adapter: strings.TrimSpace(string(adapter)),
+		})
+	}
+	return buses, nil
+}
+
+// OpenByName opens the first adapter whose name (as reported in
+// BusInfo.Adapter) contains substring.
+func OpenByName(substring string) (*File, error) {
+	buses, err := Enumerate()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range buses {
+		if strings.Contains(b.Adapter, substring) {
+			return Open(b.Bus)
+		}
+	}
+	return nil, fmt.Errorf("i2c: no adapter matching %q", substring)
+}
+
+func parseBusNum(name string) (int, bool) {
+	const prefix = "i2c-"
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(name, prefix))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -2,7 +2,10 @@ package i2c
 
 import (
 	"fmt"
+	"runtime"
 	"syscall"
+	"time"
+	"unsafe"
 )
 
 const (
@@ -17,6 +20,48 @@ const (
 	SMBUS       = 0x0720
 )
 
+// I2C_M_* flags for Msg.Flags, as defined by <linux/i2c.h>.
+const (
+	I2C_M_TEN          = 0x0010 // ten-bit slave address
+	I2C_M_RD           = 0x0001 // read data, from slave to master
+	I2C_M_NOSTART      = 0x4000 // don't generate a (repeated) start, continue the previous message
+	I2C_M_REV_DIR_ADDR = 0x2000 // flip the Rd/Wr bit
+	I2C_M_IGNORE_NAK   = 0x1000 // ignore NACK from slave
+	I2C_M_NO_RD_ACK    = 0x0800 // don't expect an ACK for the read
+	I2C_M_RECV_LEN     = 0x0400 // first received byte is the message length
+)
+
+// Funcs is a bitmask of I2C_FUNC_* adapter capabilities, as reported by the
+// I2C_FUNCS ioctl.
+type Funcs uint64
+
+const (
+	FuncI2C                 Funcs = 0x00000001
+	FuncTenBitAddr          Funcs = 0x00000002
+	FuncProtocolMangling    Funcs = 0x00000004
+	FuncSMBusPEC            Funcs = 0x00000008
+	FuncNoStart             Funcs = 0x00000010
+	FuncSlave               Funcs = 0x00000020
+	FuncSMBusBlockProcCall  Funcs = 0x00008000
+	FuncSMBusQuick          Funcs = 0x00010000
+	FuncSMBusReadByte       Funcs = 0x00020000
+	FuncSMBusWriteByte      Funcs = 0x00040000
+	FuncSMBusReadByteData   Funcs = 0x00080000
+	FuncSMBusWriteByteData  Funcs = 0x00100000
+	FuncSMBusReadWordData   Funcs = 0x00200000
+	FuncSMBusWriteWordData  Funcs = 0x00400000
+	FuncSMBusProcCall       Funcs = 0x00800000
+	FuncSMBusReadBlockData  Funcs = 0x01000000
+	FuncSMBusWriteBlockData Funcs = 0x02000000
+	FuncSMBusReadI2CBlock   Funcs = 0x04000000
+	FuncSMBusWriteI2CBlock  Funcs = 0x08000000
+)
+
+// Has reports whether all bits of want are set in f.
+func (f Funcs) Has(want Funcs) bool {
+	return f&want == want
+}
+
 type File struct {
 	bus int
 	fd  int
@@ -34,10 +79,30 @@ func Open(bus int) (*File, error) {
 	return f, nil
 }
 
+// NewFile wraps an already-open file descriptor as a File, without going
+// through Open's /dev/i2c-* lookup. It exists for tests that install a
+// mock syscall (see the mocki2c subpackage) and have no real device to
+// open; fd need not refer to a real I2C adapter in that case.
+func NewFile(fd int) *File {
+	return &File{fd: fd}
+}
+
 func (f *File) Close() {
 	syscall.Close(f.fd)
 }
 
+// Read reads from the currently selected slave address, with no register
+// addressing of its own.
+func (f *File) Read(p []byte) (int, error) {
+	return syscall.Read(f.fd, p)
+}
+
+// Write writes to the currently selected slave address, with no register
+// addressing of its own.
+func (f *File) Write(p []byte) (int, error) {
+	return syscall.Write(f.fd, p)
+}
+
 func (f *File) SetTenbit(enable bool) error {
 	var val uintptr
 	if enable {
@@ -62,15 +127,169 @@ func (f *File) SetSlaveAddr(addr uint16, force bool) error {
 	}
 }
 
+// SetRetries sets the number of times the adapter should retry a transfer
+// that comes back NACKed before giving up.
+func (f *File) SetRetries(n int) error {
+	if n < 0 {
+		return fmt.Errorf("i2c: retries must be >= 0, got %d", n)
+	}
+	return f.Ioctl(RETRIES, uintptr(n))
+}
+
+// maxTimeoutUnits is the largest value the I2C_TIMEOUT ioctl accepts,
+// expressed in its native 10ms units.
+const maxTimeoutUnits = 0xffff
+
+// SetTimeout sets how long the adapter should wait for a transfer to
+// complete. The kernel only has 10ms granularity, so d is rounded down to
+// the nearest 10ms and clamped to the range the ioctl accepts; d below
+// 10ms is rejected since it would round to 0 (no timeout).
+func (f *File) SetTimeout(d time.Duration) error {
+	if d < 10*time.Millisecond {
+		return fmt.Errorf("i2c: timeout must be at least 10ms, got %s", d)
+	}
+	units := d / (10 * time.Millisecond)
+	if units > maxTimeoutUnits {
+		units = maxTimeoutUnits
+	}
+	return f.Ioctl(TIMEOUT, uintptr(units))
+}
+
+// Funcs returns the set of functionality the underlying adapter supports.
+func (f *File) Funcs() (Funcs, error) {
+	var funcs Funcs
+	err := f.IoctlPtr(FUNCS, unsafe.Pointer(&funcs))
+	if err != nil {
+		return 0, err
+	}
+	return funcs, nil
+}
+
+// The I2C_RETRIES/TIMEOUT/SLAVE/SLAVE_FORCE/TENBIT/PEC ioctls all take
+// their argument as a plain value, not a pointer to one, so Ioctl and
+// SyscallFunc use the same uintptr-valued third argument as the real
+// syscall.Syscall; there is no pointer to pin. I2C_FUNCS/RDWR/SMBUS take
+// the address of a struct the kernel reads or fills in, so those go
+// through IoctlPtr/SyscallPtrFunc instead, which carries a genuine
+// unsafe.Pointer through every frame and only converts to uintptr inline
+// at the actual syscall, matching the pattern the Go compiler recognizes
+// as keeping the pointee alive for the duration of the call.
+
+// SyscallFunc is the shape of the raw syscall entry point Ioctl uses for
+// value-argument ioctls. Tests can replace it via SetSyscall to exercise
+// this package without a real /dev/i2c-* device.
+type SyscallFunc func(trap, a1, a2, a3 uintptr) (r1, r2 uintptr, err syscall.Errno)
+
+// syscallFn is the entry point Ioctl calls through; it defaults to the
+// real syscall and is only ever overridden by SetSyscall in tests.
+var syscallFn SyscallFunc = syscall.Syscall
+
+// SetSyscall replaces the syscall entry point used by every File's Ioctl.
+// It exists so tests (see the mocki2c subpackage) can fake an adapter
+// without real hardware; production code must never call it.
+func SetSyscall(fn SyscallFunc) {
+	syscallFn = fn
+}
+
+// Ioctl issues a value-argument ioctl (I2C_SLAVE, I2C_SLAVE_FORCE,
+// I2C_TENBIT, I2C_PEC, I2C_RETRIES, I2C_TIMEOUT): msg is the argument
+// itself, not a pointer to it.
 func (f *File) Ioctl(cmd int, msg uintptr) error {
-	_, _, e := syscall.Syscall(
-		syscall.SYS_IOCTL,
-		uintptr(f.fd),
-		uintptr(cmd),
-		msg,
-	)
+	_, _, e := syscallFn(syscall.SYS_IOCTL, uintptr(f.fd), uintptr(cmd), msg)
 	if e == 0 {
 		return nil
 	}
 	return e
 }
+
+// SyscallPtrFunc is the shape of the raw syscall entry point IoctlPtr
+// uses for pointer-argument ioctls. Tests can replace it via
+// SetSyscallPtr to exercise this package without a real /dev/i2c-*
+// device.
+type SyscallPtrFunc func(trap, a1, a2 uintptr, a3 unsafe.Pointer) (r1, r2 uintptr, err syscall.Errno)
+
+// syscallPtrFn is the entry point IoctlPtr calls through; it defaults to
+// the real syscall and is only ever overridden by SetSyscallPtr in tests.
+var syscallPtrFn SyscallPtrFunc = rawSyscallPtr
+
+func rawSyscallPtr(trap, a1, a2 uintptr, a3 unsafe.Pointer) (uintptr, uintptr, syscall.Errno) {
+	return syscall.Syscall(trap, a1, a2, uintptr(a3))
+}
+
+// SetSyscallPtr replaces the syscall entry point used by every File's
+// IoctlPtr. It exists so tests (see the mocki2c subpackage) can fake an
+// adapter without real hardware; production code must never call it.
+func SetSyscallPtr(fn SyscallPtrFunc) {
+	syscallPtrFn = fn
+}
+
+// IoctlPtr issues a pointer-argument ioctl (I2C_FUNCS, I2C_RDWR,
+// I2C_SMBUS): ptr is the address of the struct the kernel reads from or
+// fills in. Callers must pass the real pointer, not a uintptr built from
+// one, so it stays a live reference through every frame up to the
+// syscall.
+func (f *File) IoctlPtr(cmd int, ptr unsafe.Pointer) error {
+	_, _, e := syscallPtrFn(syscall.SYS_IOCTL, uintptr(f.fd), uintptr(cmd), ptr)
+	if e == 0 {
+		return nil
+	}
+	return e
+}
+
+// Msg describes a single message of an I2C_RDWR combined transaction:
+// a read or write of Buf to/from Addr, with no stop condition between
+// messages in the same Transfer call.
+type Msg struct {
+	Addr  uint16
+	Flags uint16
+	Buf   []byte
+}
+
+// rawMsg mirrors the kernel's struct i2c_msg.
+type rawMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   uintptr
+}
+
+// rawRdwrIoctlData mirrors the kernel's struct i2c_rdwr_ioctl_data.
+type rawRdwrIoctlData struct {
+	msgs uintptr
+	nmsg uint32
+}
+
+// Transfer submits msgs as a single combined I2C_RDWR transaction: all
+// messages share one repeated-START, each with its own slave address and
+// direction. For a message flagged I2C_M_RECV_LEN, Buf is resliced to the
+// length actually reported by the slave.
+func (f *File) Transfer(msgs []Msg) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	raw := make([]rawMsg, len(msgs))
+	for i := range msgs {
+		raw[i].addr = msgs[i].Addr
+		raw[i].flags = msgs[i].Flags
+		raw[i].len = uint16(len(msgs[i].Buf))
+		if len(msgs[i].Buf) > 0 {
+			raw[i].buf = uintptr(unsafe.Pointer(&msgs[i].Buf[0]))
+		}
+	}
+	data := rawRdwrIoctlData{
+		msgs: uintptr(unsafe.Pointer(&raw[0])),
+		nmsg: uint32(len(raw)),
+	}
+	err := f.IoctlPtr(RDWR, unsafe.Pointer(&data))
+	runtime.KeepAlive(msgs)
+	runtime.KeepAlive(raw)
+	if err != nil {
+		return err
+	}
+	for i := range msgs {
+		if msgs[i].Flags&I2C_M_RECV_LEN != 0 && int(raw[i].len) <= len(msgs[i].Buf) {
+			msgs[i].Buf = msgs[i].Buf[:raw[i].len]
+		}
+	}
+	return nil
+}
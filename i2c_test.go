@@ -0,0 +1,106 @@
+package i2c_test
+
+import (
+	"testing"
+
+	"go-i2c"
+	"go-i2c/testing/mocki2c"
+)
+
+func TestFileFuncs(t *testing.T) {
+	mock := mocki2c.New()
+	mock.Funcs = i2c.FuncI2C | i2c.FuncSMBusReadByteData
+	mock.Install()
+
+	f := i2c.NewFile(0)
+	funcs, err := f.Funcs()
+	if err != nil {
+		t.Fatalf("Funcs: %v", err)
+	}
+	if !funcs.Has(i2c.FuncSMBusReadByteData) {
+		t.Fatalf("Funcs() = %#x, want FuncSMBusReadByteData set", funcs)
+	}
+}
+
+func TestFileTransfer(t *testing.T) {
+	mock := mocki2c.New()
+	mock.Reads[0x50] = [][]byte{{0x12, 0x34}}
+	mock.Install()
+
+	f := i2c.NewFile(0)
+	reg := []byte{0x00}
+	data := make([]byte, 2)
+	err := f.Transfer([]i2c.Msg{
+		{Addr: 0x50, Buf: reg},
+		{Addr: 0x50, Flags: i2c.I2C_M_RD, Buf: data},
+	})
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if data[0] != 0x12 || data[1] != 0x34 {
+		t.Fatalf("data = %x, want [12 34]", data)
+	}
+	if len(mock.Transfers) != 1 || len(mock.Transfers[0]) != 2 {
+		t.Fatalf("Transfers = %v, want one transfer with two messages", mock.Transfers)
+	}
+}
+
+func TestBusDeviceSMBus(t *testing.T) {
+	mock := mocki2c.New()
+	mock.Funcs = i2c.FuncSMBusReadByteData | i2c.FuncSMBusWriteByteData
+	mock.Install()
+
+	bus := i2c.NewBus(i2c.NewFile(0))
+	dev := bus.Device(0x50, false)
+
+	if err := dev.WriteByteData(0x10, 0x42); err != nil {
+		t.Fatalf("WriteByteData: %v", err)
+	}
+	got, err := dev.ReadByteData(0x10)
+	if err != nil {
+		t.Fatalf("ReadByteData: %v", err)
+	}
+	if got != 0x42 {
+		t.Fatalf("ReadByteData(0x10) = %#x, want 0x42", got)
+	}
+	if mock.SlaveAddr != 0x50 {
+		t.Fatalf("SlaveAddr = %#x, want 0x50", mock.SlaveAddr)
+	}
+}
+
+func TestBusDeviceSMBusUnsupported(t *testing.T) {
+	mock := mocki2c.New()
+	mock.Install() // Funcs left at zero: no SMBus capability advertised.
+
+	bus := i2c.NewBus(i2c.NewFile(0))
+	dev := bus.Device(0x50, false)
+
+	err := dev.WriteByteData(0x10, 0x42)
+	if _, ok := err.(*i2c.ErrUnsupported); !ok {
+		t.Fatalf("WriteByteData error = %v (%T), want *i2c.ErrUnsupported", err, err)
+	}
+}
+
+func TestBusDeviceReadI2CBlockDataClamps(t *testing.T) {
+	mock := mocki2c.New()
+	mock.Funcs = i2c.FuncSMBusReadI2CBlock
+	mock.Blocks[0x10] = []byte{1, 2, 3, 4, 5}
+	mock.Install()
+
+	bus := i2c.NewBus(i2c.NewFile(0))
+	dev := bus.Device(0x50, false)
+
+	// A length over the SMBus layer's 32 byte limit must be clamped
+	// client-side rather than handed to the kernel as-is.
+	got, err := dev.ReadI2CBlockData(0x10, 1000)
+	if err != nil {
+		t.Fatalf("ReadI2CBlockData: %v", err)
+	}
+	if len(got) != 5 {
+		t.Fatalf("ReadI2CBlockData returned %d bytes, want 5", len(got))
+	}
+
+	if _, err := dev.ReadI2CBlockData(0x10, -1); err != nil {
+		t.Fatalf("ReadI2CBlockData with negative length: %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package smbus
 
 import (
+	"errors"
+	"fmt"
+	"time"
 	"unsafe"
 
 	"go-i2c"
@@ -19,39 +22,54 @@ import (
 // Count (8 bits): A data byte containing the length of a block operation.
 // [..]: Data sent by I2C device, as opposed to data sent by the host adapter.
 
-// SMBus Rd/Wr bit
-type RWBit byte
+// RWBit and TXType are aliases for i2c's exported SMBusRW/SMBusSize, so
+// this package, bus.go's Device, and testing/mocki2c all share one
+// definition of the kernel's i2c_smbus_ioctl_data layout and its RW/size
+// enums instead of each mirroring the ABI independently.
+type RWBit = i2c.SMBusRW
 
 const (
-	RWBitWrite RWBit = iota
-	RWBitRead
+	RWBitWrite = i2c.SMBusWrite
+	RWBitRead  = i2c.SMBusRead
 )
 
 // SMBus transaction types
-type TXType uint32
+type TXType = i2c.SMBusSize
 
 const (
-	TXTypeQuick TXType = iota
-	TXTypeByte
-	TXTypeByteData
-	TXTypeWordData
-	TXTypeProcessCall
-	TXTypeBlockData
-	TXTypeI2CBlockBroken
-	TXTypeBlockProcessCall // SMBus 2.0
-	TXTypeI2CBlockData
+	TXTypeQuick            = i2c.SMBusQuick
+	TXTypeByte             = i2c.SMBusByte
+	TXTypeByteData         = i2c.SMBusByteData
+	TXTypeWordData         = i2c.SMBusWordData
+	TXTypeProcessCall      = i2c.SMBusProcCall
+	TXTypeBlockData        = i2c.SMBusBlockData
+	TXTypeI2CBlockBroken   = i2c.SMBusI2CBlockBroken
+	TXTypeBlockProcessCall = i2c.SMBusBlockProcCall // SMBus 2.0
+	TXTypeI2CBlockData     = i2c.SMBusI2CBlockData
 )
 
-type Msg struct {
-	RW      RWBit
-	Command byte
-	pad     [2]byte
-	TX      TXType
-	Data    uintptr
-}
+// Msg is an alias for i2c.SMBusMsg; see TXType.
+type Msg = i2c.SMBusMsg
 
 type File struct {
-	f *i2c.File
+	f       *i2c.File
+	funcs   i2c.Funcs
+	funcsOK bool
+}
+
+// NewFile wraps an already-open i2c.File as an SMBus device, caching its
+// I2C_FUNCS capabilities the way Open does. It exists so callers that
+// already hold an *i2c.File (including tests installing a mock syscall
+// via the mocki2c subpackage) can build a smbus.File without going
+// through Open's /dev/i2c-* lookup.
+func NewFile(dev *i2c.File) *File {
+	f := new(File)
+	f.f = dev
+	if funcs, err := dev.Funcs(); err == nil {
+		f.funcs = funcs
+		f.funcsOK = true
+	}
+	return f
 }
 
 func Open(bus int) (*File, error) {
@@ -59,15 +77,38 @@ func Open(bus int) (*File, error) {
 	if err != nil {
 		return nil, err
 	}
-	f := new(File)
-	f.f = dev
-	return f, nil
+	return NewFile(dev), nil
 }
 
 func (f *File) Close() {
 	f.f.Close()
 }
 
+// Funcs returns the adapter capabilities cached at Open. It is the zero
+// value if the adapter did not answer I2C_FUNCS.
+func (f *File) Funcs() i2c.Funcs {
+	return f.funcs
+}
+
+// ErrUnsupported is returned by a transaction when the underlying adapter
+// lacks the capability the transaction requires, as reported by I2C_FUNCS.
+type ErrUnsupported struct {
+	Func string
+}
+
+func (e *ErrUnsupported) Error() string {
+	return fmt.Sprintf("smbus: adapter does not support %s", e.Func)
+}
+
+// requiredFunc returns the I2C_FUNC_* bit (and a human-readable name) that
+// the adapter must report to carry out a transaction of type tx and
+// direction rw, mirroring the kernel's i2c-core-smbus check. It defers
+// to i2c.RequiredSMBusFunc, which bus.go's Device also uses, so the two
+// packages can't drift apart on this mapping.
+func requiredFunc(tx TXType, rw RWBit) (i2c.Funcs, string) {
+	return i2c.RequiredSMBusFunc(tx, rw)
+}
+
 func (f *File) SetTenbit(enable bool) error {
 	return f.f.SetTenbit(enable)
 }
@@ -80,6 +121,14 @@ func (f *File) SetSlaveAddr(addr uint16, force bool) error {
 	return f.f.SetSlaveAddr(addr, force)
 }
 
+func (f *File) SetRetries(n int) error {
+	return f.f.SetRetries(n)
+}
+
+func (f *File) SetTimeout(d time.Duration) error {
+	return f.f.SetTimeout(d)
+}
+
 // WriteQuick sends a single bit to the device,
 // at the place of the Rd/Wr bit.
 // There is no equivalent Read Quick command.
@@ -87,8 +136,8 @@ func (f *File) SetSlaveAddr(addr uint16, force bool) error {
 // A Addr Rd/Wr [A] P
 func (f *File) WriteQuick(rwbit RWBit) error {
 	m := Msg{
-		RW: rwbit,
-		TX: TXTypeQuick,
+		RW:   rwbit,
+		Size: TXTypeQuick,
 	}
 	return f.Do(&m)
 }
@@ -104,7 +153,7 @@ func (f *File) ReadByte() (byte, error) {
 	var data [4]byte
 	m := Msg{
 		RW:   RWBitRead,
-		TX:   TXTypeByte,
+		Size: TXTypeByte,
 		Data: uintptr(unsafe.Pointer(&data[0])),
 	}
 	err := f.Do(&m)
@@ -123,7 +172,7 @@ func (f *File) WriteByte(data byte) error {
 	m := Msg{
 		RW:      RWBitWrite,
 		Command: data,
-		TX:      TXTypeByte,
+		Size:    TXTypeByte,
 	}
 	return f.Do(&m)
 }
@@ -138,7 +187,7 @@ func (f *File) ReadByteData(reg byte) (byte, error) {
 	m := Msg{
 		RW:      RWBitRead,
 		Command: reg,
-		TX:      TXTypeByteData,
+		Size:    TXTypeByteData,
 		Data:    uintptr(unsafe.Pointer(&data[0])),
 	}
 	err := f.Do(&m)
@@ -158,7 +207,7 @@ func (f *File) ReadWordData(reg byte) (uint16, error) {
 	m := Msg{
 		RW:      RWBitRead,
 		Command: reg,
-		TX:      TXTypeWordData,
+		Size:    TXTypeWordData,
 		Data:    uintptr(unsafe.Pointer(&data)),
 	}
 	err := f.Do(&m)
@@ -178,7 +227,7 @@ func (f *File) WriteByteData(reg, data byte) error {
 	m := Msg{
 		RW:      RWBitWrite,
 		Command: reg,
-		TX:      TXTypeByteData,
+		Size:    TXTypeByteData,
 		Data:    uintptr(unsafe.Pointer(&data)),
 	}
 	return f.Do(&m)
@@ -193,7 +242,7 @@ func (f *File) WriteWordData(reg byte, data uint16) error {
 	m := Msg{
 		RW:      RWBitWrite,
 		Command: reg,
-		TX:      TXTypeWordData,
+		Size:    TXTypeWordData,
 		Data:    uintptr(unsafe.Pointer(&data)),
 	}
 	return f.Do(&m)
@@ -203,12 +252,13 @@ func (f *File) WriteWordData(reg byte, data uint16) error {
 // sends 16 bits of data to it, and reads 16 bits of data in return.
 //
 // S Addr Wr [A] Comm [A] DataLow [A] DataHigh [A]
-//                           S Addr Rd [A] [DataLow] A [DataHigh] NA P
+//
+//	S Addr Rd [A] [DataLow] A [DataHigh] NA P
 func (f *File) ProcessCall(reg byte, data uint16) (uint16, error) {
 	m := Msg{
 		RW:      RWBitWrite,
 		Command: reg,
-		TX:      TXTypeProcessCall,
+		Size:    TXTypeProcessCall,
 		Data:    uintptr(unsafe.Pointer(&data)),
 	}
 	err := f.Do(&m)
@@ -223,13 +273,14 @@ func (f *File) ProcessCall(reg byte, data uint16) (uint16, error) {
 // The amount of data is specified by the device in the Count byte.
 //
 // S Addr Wr [A] Comm [A]
-//            S Addr Rd [A] [Count] A [Data] A [Data] A ... A [Data] NA P
+//
+//	S Addr Rd [A] [Count] A [Data] A [Data] A ... A [Data] NA P
 func (f *File) ReadBlockData(reg byte) ([]byte, error) {
 	var data [34]byte
 	m := Msg{
 		RW:      RWBitRead,
 		Command: reg,
-		TX:      TXTypeBlockData,
+		Size:    TXTypeBlockData,
 		Data:    uintptr(unsafe.Pointer(&data[0])),
 	}
 	err := f.Do(&m)
@@ -253,7 +304,7 @@ func (f *File) WriteBlockData(reg byte, data []byte) (int, error) {
 	m := Msg{
 		RW:      RWBitWrite,
 		Command: reg,
-		TX:      TXTypeBlockData,
+		Size:    TXTypeBlockData,
 		Data:    uintptr(unsafe.Pointer(&buf[0])),
 	}
 	return n, f.Do(&m)
@@ -264,7 +315,8 @@ func (f *File) WriteBlockData(reg byte, data []byte) (int, error) {
 // 1 to 31 bytes of data to it, and reads 1 to 31 bytes of data in return.
 //
 // S Addr Wr [A] Comm [A] Count [A] Data [A] ...
-//                              S Addr Rd [A] [Count] A [Data] ... A P
+//
+//	S Addr Rd [A] [Count] A [Data] ... A P
 func (f *File) BlockProcessCall(reg byte, p []byte) (int, error) {
 	var data [34]byte
 	n := copy(data[1:], p)
@@ -272,7 +324,7 @@ func (f *File) BlockProcessCall(reg byte, p []byte) (int, error) {
 	m := Msg{
 		RW:      RWBitWrite,
 		Command: reg,
-		TX:      TXTypeBlockProcessCall,
+		Size:    TXTypeBlockProcessCall,
 		Data:    uintptr(unsafe.Pointer(&data[0])),
 	}
 	err := f.Do(&m)
@@ -293,7 +345,8 @@ func (f *File) BlockProcessCall(reg byte, p []byte) (int, error) {
 // designated register that is specified through the Comm byte.
 //
 // S Addr Wr [A] Comm [A]
-//            S Addr Rd [A] [Data] A [Data] A ... A [Data] NA P
+//
+//	S Addr Rd [A] [Data] A [Data] A ... A [Data] NA P
 func (f *File) ReadI2CBlockData(reg byte, p []byte) (int, error) {
 	var data [34]byte
 	n := len(p)
@@ -310,7 +363,7 @@ func (f *File) ReadI2CBlockData(reg byte, p []byte) (int, error) {
 	m := Msg{
 		RW:      RWBitRead,
 		Command: reg,
-		TX:      tx,
+		Size:    tx,
 		Data:    uintptr(unsafe.Pointer(&data[0])),
 	}
 	err := f.Do(&m)
@@ -336,12 +389,46 @@ func (f *File) WriteI2CBlockData(reg byte, data []byte) (int, error) {
 	m := Msg{
 		RW:      RWBitWrite,
 		Command: reg,
-		TX:      TXTypeI2CBlockBroken,
+		Size:    TXTypeI2CBlockBroken,
 		Data:    uintptr(unsafe.Pointer(&buf[0])),
 	}
 	return n, f.Do(&m)
 }
 
+// ErrAlertUnsupported is returned by SetAlertHandler and NotifyChan.
+//
+// There is no generic userspace interface for SMBus Alert or Host
+// Notify to build either on: real adapters deliver them via an
+// IRQ/workqueue straight to i2c_handle_smbus_alert() (see
+// drivers/i2c/i2c-smbus.c), not through any ioctl or sysfs file a
+// process can poll. In particular, neither
+// /sys/bus/i2c/devices/i2c-<n>/smbus_alert nor an "I2C_SMBUS_ALERT"
+// ioctl exists upstream, so this package does not use either, and it
+// does not receive SMBus Alert/Host Notify from userspace.
+var ErrAlertUnsupported = errors.New("smbus: SMBus Alert/Host Notify has no generic userspace interface")
+
+// AlertEvent would describe one SMBus Alert / Host Notify received from
+// the bus, if this package could receive one; see ErrAlertUnsupported.
+type AlertEvent struct {
+	Addr uint16
+	Data uint16
+}
+
+// SetAlertHandler always returns ErrAlertUnsupported; see ErrAlertUnsupported.
+func (f *File) SetAlertHandler(fn func(addr uint16, data uint16)) error {
+	return ErrAlertUnsupported
+}
+
+// NotifyChan always returns ErrAlertUnsupported; see ErrAlertUnsupported.
+func (f *File) NotifyChan() (<-chan AlertEvent, error) {
+	return nil, ErrAlertUnsupported
+}
+
 func (f *File) Do(msg *Msg) error {
-	return f.f.Ioctl(i2c.SMBUS, uintptr(unsafe.Pointer(msg)))
+	if f.funcsOK {
+		if need, name := requiredFunc(msg.Size, msg.RW); need != 0 && !f.funcs.Has(need) {
+			return &ErrUnsupported{Func: name}
+		}
+	}
+	return f.f.IoctlPtr(i2c.SMBUS, unsafe.Pointer(msg))
 }
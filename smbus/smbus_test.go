@@ -0,0 +1,57 @@
+package smbus_test
+
+import (
+	"testing"
+
+	"go-i2c"
+	"go-i2c/smbus"
+	"go-i2c/testing/mocki2c"
+)
+
+func TestFileByteData(t *testing.T) {
+	mock := mocki2c.New()
+	mock.Funcs = i2c.FuncSMBusReadByteData | i2c.FuncSMBusWriteByteData
+	mock.Install()
+
+	f := smbus.NewFile(i2c.NewFile(0))
+	defer f.Close()
+
+	if err := f.WriteByteData(0x10, 0x42); err != nil {
+		t.Fatalf("WriteByteData: %v", err)
+	}
+	got, err := f.ReadByteData(0x10)
+	if err != nil {
+		t.Fatalf("ReadByteData: %v", err)
+	}
+	if got != 0x42 {
+		t.Fatalf("ReadByteData(0x10) = %#x, want 0x42", got)
+	}
+}
+
+func TestFileUnsupported(t *testing.T) {
+	mock := mocki2c.New() // Funcs left at zero: no SMBus capability advertised.
+	mock.Install()
+
+	f := smbus.NewFile(i2c.NewFile(0))
+	defer f.Close()
+
+	err := f.WriteByteData(0x10, 0x42)
+	if _, ok := err.(*smbus.ErrUnsupported); !ok {
+		t.Fatalf("WriteByteData error = %v (%T), want *smbus.ErrUnsupported", err, err)
+	}
+}
+
+func TestFileAlertUnsupported(t *testing.T) {
+	mock := mocki2c.New()
+	mock.Install()
+
+	f := smbus.NewFile(i2c.NewFile(0))
+	defer f.Close()
+
+	if err := f.SetAlertHandler(func(addr, data uint16) {}); err != smbus.ErrAlertUnsupported {
+		t.Fatalf("SetAlertHandler error = %v, want smbus.ErrAlertUnsupported", err)
+	}
+	if _, err := f.NotifyChan(); err != smbus.ErrAlertUnsupported {
+		t.Fatalf("NotifyChan error = %v, want smbus.ErrAlertUnsupported", err)
+	}
+}
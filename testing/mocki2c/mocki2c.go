@@ -0,0 +1,208 @@
+// Package mocki2c is a fake I2C adapter for exercising drivers built on
+// go-i2c without real hardware. It installs itself via i2c.SetSyscall and
+// i2c.SetSyscallPtr and interprets a subset of the ioctl commands a real
+// /dev/i2c-* would: SLAVE, SLAVE_FORCE, TENBIT, FUNCS, RDWR, and SMBUS
+// byte-data, word-data and block transactions. Any other command or
+// SMBus transaction size fails with ENOTTY.
+package mocki2c
+
+import (
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"go-i2c"
+)
+
+// Msg is a recorded copy of one message from an I2C_RDWR transaction.
+type Msg struct {
+	Addr  uint16
+	Flags uint16
+	Buf   []byte
+}
+
+// Device is a fake adapter. Callers set up its exported fields to
+// script responses, then call Install to route every i2c.File ioctl
+// through it for the lifetime of a test.
+type Device struct {
+	mu sync.Mutex
+
+	// Funcs is returned for the I2C_FUNCS ioctl.
+	Funcs i2c.Funcs
+
+	// Regs and Words back SMBus byte-data and word-data transactions,
+	// keyed by the command (register) byte.
+	Regs  map[byte]byte
+	Words map[byte]uint16
+	// Blocks backs SMBus and I2C block-data transactions.
+	Blocks map[byte][]byte
+
+	// Reads queues the bytes returned for the next I2C_M_RD message on
+	// an I2C_RDWR transfer to a given slave address; each Transfer call
+	// pops one entry per read message.
+	Reads map[uint16][][]byte
+
+	// ErrOn maps an ioctl command (e.g. i2c.SMBUS) to an errno that
+	// should be returned instead of servicing the request.
+	ErrOn map[int]syscall.Errno
+
+	// SlaveAddr and TenBit track the last SLAVE/SLAVE_FORCE and TENBIT
+	// ioctls the device under test issued.
+	SlaveAddr uint16
+	TenBit    bool
+
+	// Transfers records every I2C_RDWR transaction, in order.
+	Transfers [][]Msg
+}
+
+// New returns an empty Device ready to be configured and Installed.
+func New() *Device {
+	return &Device{
+		Regs:   make(map[byte]byte),
+		Words:  make(map[byte]uint16),
+		Blocks: make(map[byte][]byte),
+		Reads:  make(map[uint16][][]byte),
+		ErrOn:  make(map[int]syscall.Errno),
+	}
+}
+
+// Install routes every ioctl a go-i2c File issues to d, for as long as the
+// process runs or until another Install/i2c.SetSyscall(Ptr) call replaces
+// it. It is meant for use from a test's setup code, never from production
+// code.
+func (d *Device) Install() {
+	i2c.SetSyscall(d.syscallValue)
+	i2c.SetSyscallPtr(d.syscallPtr)
+}
+
+// syscallValue handles the value-argument ioctls (I2C_SLAVE,
+// I2C_SLAVE_FORCE, I2C_TENBIT); it is installed as the package's
+// SyscallFunc.
+func (d *Device) syscallValue(trap, a1, a2, a3 uintptr) (uintptr, uintptr, syscall.Errno) {
+	if trap != syscall.SYS_IOCTL {
+		return 0, 0, syscall.ENOSYS
+	}
+	cmd := int(a2)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if errno, failing := d.ErrOn[cmd]; failing {
+		return ^uintptr(0), 0, errno
+	}
+
+	switch cmd {
+	case i2c.SLAVE, i2c.SLAVE_FORCE:
+		d.SlaveAddr = uint16(a3)
+		return 0, 0, 0
+	case i2c.TENBIT:
+		d.TenBit = a3 != 0
+		return 0, 0, 0
+	default:
+		return 0, 0, syscall.ENOTTY
+	}
+}
+
+// syscallPtr handles the pointer-argument ioctls (I2C_FUNCS, I2C_RDWR,
+// I2C_SMBUS); it is installed as the package's SyscallPtrFunc.
+func (d *Device) syscallPtr(trap, a1, a2 uintptr, a3 unsafe.Pointer) (uintptr, uintptr, syscall.Errno) {
+	if trap != syscall.SYS_IOCTL {
+		return 0, 0, syscall.ENOSYS
+	}
+	cmd := int(a2)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if errno, failing := d.ErrOn[cmd]; failing {
+		return ^uintptr(0), 0, errno
+	}
+
+	switch cmd {
+	case i2c.FUNCS:
+		*(*i2c.Funcs)(a3) = d.Funcs
+		return 0, 0, 0
+	case i2c.RDWR:
+		d.doRDWR(a3)
+		return 0, 0, 0
+	case i2c.SMBUS:
+		return 0, 0, d.doSMBus(a3)
+	default:
+		return 0, 0, syscall.ENOTTY
+	}
+}
+
+// rawMsg and rawRdwrIoctlData mirror the unexported layouts in go-i2c
+// (struct i2c_msg / struct i2c_rdwr_ioctl_data); they have the same field
+// types and order, so reinterpreting a pointer from one as the other is
+// safe regardless of which package defined it.
+type rawMsg struct {
+	addr  uint16
+	flags uint16
+	len   uint16
+	buf   uintptr
+}
+
+type rawRdwrIoctlData struct {
+	msgs uintptr
+	nmsg uint32
+}
+
+// ptrFromUintptr reinterprets a uintptr that actually holds a pointer
+// (as the kernel ioctl structs in this file do) back into unsafe.Pointer.
+func ptrFromUintptr(u uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&u))
+}
+
+func (d *Device) doRDWR(a3 unsafe.Pointer) {
+	data := (*rawRdwrIoctlData)(a3)
+	msgs := unsafe.Slice((*rawMsg)(ptrFromUintptr(data.msgs)), int(data.nmsg))
+	rec := make([]Msg, len(msgs))
+	for i := range msgs {
+		m := &msgs[i]
+		buf := unsafe.Slice((*byte)(ptrFromUintptr(m.buf)), int(m.len))
+		if m.flags&i2c.I2C_M_RD != 0 {
+			if q := d.Reads[m.addr]; len(q) > 0 {
+				copy(buf, q[0])
+				d.Reads[m.addr] = q[1:]
+			}
+		}
+		rec[i] = Msg{Addr: m.addr, Flags: m.flags, Buf: append([]byte(nil), buf...)}
+	}
+	d.Transfers = append(d.Transfers, rec)
+}
+
+// doSMBus interprets a3 as an *i2c.SMBusMsg — the same exported layout
+// i2c's Device and the smbus package build, so there is nothing here to
+// keep in sync with the kernel ABI independently.
+func (d *Device) doSMBus(a3 unsafe.Pointer) syscall.Errno {
+	msg := (*i2c.SMBusMsg)(a3)
+	switch msg.Size {
+	case i2c.SMBusByteData:
+		p := (*byte)(ptrFromUintptr(msg.Data))
+		if msg.RW == i2c.SMBusRead {
+			*p = d.Regs[msg.Command]
+		} else {
+			d.Regs[msg.Command] = *p
+		}
+	case i2c.SMBusWordData:
+		p := (*uint16)(ptrFromUintptr(msg.Data))
+		if msg.RW == i2c.SMBusRead {
+			*p = d.Words[msg.Command]
+		} else {
+			d.Words[msg.Command] = *p
+		}
+	case i2c.SMBusBlockData, i2c.SMBusI2CBlockBroken, i2c.SMBusI2CBlockData:
+		buf := unsafe.Slice((*byte)(ptrFromUintptr(msg.Data)), 34)
+		if msg.RW == i2c.SMBusRead {
+			n := copy(buf[1:], d.Blocks[msg.Command])
+			buf[0] = byte(n)
+		} else {
+			n := buf[0]
+			d.Blocks[msg.Command] = append([]byte(nil), buf[1:1+n]...)
+		}
+	default:
+		return syscall.ENOTTY
+	}
+	return 0
+}